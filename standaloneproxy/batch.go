@@ -0,0 +1,253 @@
+package standaloneproxy
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aurora-is-near/relayer2-base/types/common"
+	"github.com/aurora-is-near/relayer2-base/types/engine"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// defaultMaxBatchSize bounds how many proxied calls are coalesced into a
+// single socket round trip when Config.MaxBatchSize isn't set.
+const defaultMaxBatchSize = 100
+
+// BatchCall is one call within a JSON-RPC batch request.
+type BatchCall struct {
+	Name     string
+	Args     []any
+	Response *any
+}
+
+// BatchProcessor is an optional extension of endpoint.Processor: a dispatcher
+// that has a whole JSON-RPC batch to send at once can type-assert a Processor
+// for this interface and call PreBatch with every call in the batch, instead
+// of invoking Pre once per call, to give batch-aware processors a chance to
+// coalesce proxied work.
+//
+// NOTE: nothing in this package or its callers has, as of this commit, been
+// confirmed to perform that type assertion — relayer2-base's dispatcher lives
+// outside this repo, so whether it actually probes for BatchProcessor (and
+// under what name/signature) is unverified here. Until that's confirmed, a
+// batch of N proxied calls still gets N individual Pre invocations. For every
+// proxied method except debug_traceTransaction (which Pre sends through
+// RPCClient.TraceTransaction), each of those goes through planProxyCall +
+// RequestBatch (see runProxyPlan in proxy.go) the same way PreBatch does, so
+// that codepath is real and
+// exercised even though the N-at-once coalescing PreBatch adds on top of it
+// is not yet known to be reachable.
+type BatchProcessor interface {
+	PreBatch(ctx context.Context, calls []BatchCall) (handled []bool, errs []error)
+}
+
+var _ BatchProcessor = (*StandaloneProxy)(nil)
+
+// proxyPlan is everything needed to forward one BatchCall to the refiner and
+// decode its response; it's also reusable for the eth_estimateGas/debug_trace*
+// cases in Pre's single-call path.
+type proxyPlan struct {
+	method string
+	params []any
+	decode func(raw []byte) (any, error)
+}
+
+// planProxyCall validates and builds a proxyPlan for name, or returns a nil
+// plan if name isn't one this proxy forwards.
+func planProxyCall(name string, args []any) (*proxyPlan, error) {
+	switch name {
+	case "debug_traceTransaction":
+		if len(args) != 1 {
+			return nil, errors.New("invalid params")
+		}
+		hash, ok := args[0].(common.H256)
+		if !ok {
+			return nil, errors.New("invalid params")
+		}
+		return &proxyPlan{
+			method: name,
+			params: []any{hash},
+			decode: func(raw []byte) (any, error) { return decodeTraceTransactionResponse(raw) },
+		}, nil
+
+	case "debug_traceCall":
+		if len(args) < 2 {
+			return nil, errors.New("invalid params")
+		}
+		tx, ok := args[0].(engine.TransactionForCall)
+		if !ok {
+			return nil, errors.New("invalid params")
+		}
+		blockNumberOrHash, ok := args[1].(*common.BlockNumberOrHash)
+		if !ok {
+			return nil, errors.New("invalid params")
+		}
+		cfg, err := tracerConfigArg(args, 2)
+		if err != nil {
+			return nil, err
+		}
+		return &proxyPlan{
+			method: name,
+			params: []any{tx, blockNumberOrHash, cfg},
+			decode: func(raw []byte) (any, error) { return decodeTraceResponse(cfg.name(), raw, false) },
+		}, nil
+
+	case "debug_traceBlockByNumber":
+		if len(args) < 1 {
+			return nil, errors.New("invalid params")
+		}
+		number, ok := args[0].(*common.BN64)
+		if !ok {
+			return nil, errors.New("invalid params")
+		}
+		cfg, err := tracerConfigArg(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return &proxyPlan{
+			method: name,
+			params: []any{number, cfg},
+			decode: func(raw []byte) (any, error) { return decodeTraceResponse(cfg.name(), raw, true) },
+		}, nil
+
+	case "debug_traceBlockByHash":
+		if len(args) < 1 {
+			return nil, errors.New("invalid params")
+		}
+		hash, ok := args[0].(common.H256)
+		if !ok {
+			return nil, errors.New("invalid params")
+		}
+		cfg, err := tracerConfigArg(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return &proxyPlan{
+			method: name,
+			params: []any{hash, cfg},
+			decode: func(raw []byte) (any, error) { return decodeTraceResponse(cfg.name(), raw, true) },
+		}, nil
+
+	case "debug_traceBlock":
+		if len(args) < 1 {
+			return nil, errors.New("invalid params")
+		}
+		rlp, ok := args[0].(hexutil.Bytes)
+		if !ok {
+			return nil, errors.New("invalid params")
+		}
+		cfg, err := tracerConfigArg(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return &proxyPlan{
+			method: name,
+			params: []any{rlp, cfg},
+			decode: func(raw []byte) (any, error) { return decodeTraceResponse(cfg.name(), raw, true) },
+		}, nil
+
+	case "eth_estimateGas":
+		if len(args) < 2 {
+			return nil, errors.New("invalid params")
+		}
+		tx, ok := args[0].(engine.TransactionForCall)
+		if !ok {
+			return nil, errors.New("invalid params")
+		}
+		blockNumberOrHash, ok := args[1].(*common.BlockNumberOrHash)
+		if !ok {
+			return nil, errors.New("invalid params")
+		}
+		if blockNumberOrHash == nil {
+			latest := common.LatestBlockNumber
+			blockNumberOrHash = &common.BlockNumberOrHash{BlockNumber: &latest}
+		}
+		var blockParam interface{} = blockNumberOrHash.BlockNumber
+		switch *blockNumberOrHash.BlockNumber {
+		case common.EarliestBlockNumber:
+			blockParam = "earliest"
+		case common.LatestBlockNumber:
+			blockParam = "latest"
+		case common.PendingBlockNumber:
+			blockParam = "pending"
+		}
+		return &proxyPlan{
+			method: name,
+			params: []any{tx, blockParam},
+			decode: func(raw []byte) (any, error) { return parseEstimateGasResponse(raw) },
+		}, nil
+
+	default:
+		return nil, nil
+	}
+}
+
+// PreBatch implements BatchProcessor. Proxied calls (eth_estimateGas,
+// debug_trace*) are coalesced into as few socket round trips as possible,
+// bounded by Config.MaxBatchSize; calls this proxy doesn't handle come back
+// with handled=false so the dispatcher can run them through Pre/locally as
+// usual.
+func (l *StandaloneProxy) PreBatch(ctx context.Context, calls []BatchCall) ([]bool, []error) {
+	handled := make([]bool, len(calls))
+	errs := make([]error, len(calls))
+	plans := make([]*proxyPlan, len(calls))
+
+	var proxied []int
+	for i, c := range calls {
+		plan, err := planProxyCall(c.Name, c.Args)
+		if err != nil {
+			handled[i] = true
+			errs[i] = err
+			continue
+		}
+		if plan == nil {
+			continue
+		}
+		plans[i] = plan
+		proxied = append(proxied, i)
+	}
+
+	maxBatch := l.Config.MaxBatchSize
+	if maxBatch <= 0 {
+		maxBatch = defaultMaxBatchSize
+	}
+
+	for start := 0; start < len(proxied); start += maxBatch {
+		end := start + maxBatch
+		if end > len(proxied) {
+			end = len(proxied)
+		}
+		chunk := proxied[start:end]
+
+		reqs := make([]BatchRequest, len(chunk))
+		for j, idx := range chunk {
+			reqs[j] = BatchRequest{Method: plans[idx].method, Params: plans[idx].params}
+		}
+
+		results, err := l.client.RequestBatch(ctx, reqs)
+		if err != nil {
+			for _, idx := range chunk {
+				handled[idx] = true
+				errs[idx] = err
+			}
+			continue
+		}
+
+		for j, idx := range chunk {
+			handled[idx] = true
+			res := results[j]
+			if res.Err != nil {
+				errs[idx] = res.Err
+				continue
+			}
+			decoded, err := plans[idx].decode(res.Raw)
+			if err != nil {
+				errs[idx] = err
+				continue
+			}
+			*calls[idx].Response = decoded
+		}
+	}
+
+	return handled, errs
+}
@@ -0,0 +1,183 @@
+package standaloneproxy
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aurora-is-near/relayer2-base/types/common"
+	"github.com/buger/jsonparser"
+)
+
+// traceTxRequestIDs parses the batch request frame serverConn received and
+// returns the "id" of each element, in order.
+func traceTxRequestIDs(t *testing.T, req []byte) []int64 {
+	t.Helper()
+	var ids []int64
+	if _, err := jsonparser.ArrayEach(req, func(value []byte, _ jsonparser.ValueType, _ int, _ error) {
+		id, err := jsonparser.GetInt(value, "id")
+		if err != nil {
+			t.Fatalf("jsonparser.GetInt(id): %v", err)
+		}
+		ids = append(ids, id)
+	}); err != nil {
+		t.Fatalf("parsing batch request frame: %v", err)
+	}
+	return ids
+}
+
+func TestPreBatchReturnsUnhandledForMethodsItDoesNotProxy(t *testing.T) {
+	rc, _ := newTestClient(t)
+	l := &StandaloneProxy{Config: &Config{MaxBatchSize: defaultMaxBatchSize}, client: rc}
+
+	var resp any
+	calls := []BatchCall{{Name: "eth_chainId", Args: nil, Response: &resp}}
+
+	handled, errs := l.PreBatch(context.Background(), calls)
+	if handled[0] {
+		t.Fatal("PreBatch claimed to handle a method it doesn't proxy")
+	}
+	if errs[0] != nil {
+		t.Fatalf("errs[0] = %v, want nil for an unhandled call", errs[0])
+	}
+}
+
+func TestPreBatchChunksRequestsByMaxBatchSize(t *testing.T) {
+	rc, serverConn := newTestClient(t)
+	l := &StandaloneProxy{Config: &Config{MaxBatchSize: 2}, client: rc}
+
+	const n = 5
+	calls := make([]BatchCall, n)
+	responses := make([]any, n)
+	for i := range calls {
+		calls[i] = BatchCall{Name: "debug_traceTransaction", Args: []any{common.H256{}}, Response: &responses[i]}
+	}
+
+	var chunkSizes []int
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for len(chunkSizes) == 0 || sum(chunkSizes) < n {
+			req := readFrame(t, serverConn)
+			ids := traceTxRequestIDs(t, req)
+			chunkSizes = append(chunkSizes, len(ids))
+
+			resp := "["
+			for i, id := range ids {
+				if i > 0 {
+					resp += ","
+				}
+				resp += fmt.Sprintf(`{"id":%d,"jsonrpc":"2.0","result":{}}`, id)
+			}
+			resp += "]"
+			writeFrame(t, serverConn, []byte(resp))
+		}
+	}()
+
+	handled, errs := l.PreBatch(context.Background(), calls)
+	<-done
+
+	for i := range calls {
+		if !handled[i] {
+			t.Fatalf("call %d: handled = false, want true", i)
+		}
+		if errs[i] != nil {
+			t.Fatalf("call %d: err = %v, want nil", i, errs[i])
+		}
+		if responses[i] == nil {
+			t.Fatalf("call %d: response was never set", i)
+		}
+	}
+
+	if got, want := chunkSizes, []int{2, 2, 1}; !equalInts(got, want) {
+		t.Fatalf("chunk sizes = %v, want %v", got, want)
+	}
+}
+
+func TestPreBatchPartialFailureOnlyFailsTheBrokenCall(t *testing.T) {
+	rc, serverConn := newTestClient(t)
+	l := &StandaloneProxy{Config: &Config{MaxBatchSize: defaultMaxBatchSize}, client: rc}
+
+	var respA, respB any
+	calls := []BatchCall{
+		{Name: "debug_traceTransaction", Args: []any{common.H256{}}, Response: &respA},
+		{Name: "debug_traceTransaction", Args: []any{common.H256{}}, Response: &respB},
+	}
+
+	go func() {
+		req := readFrame(t, serverConn)
+		ids := traceTxRequestIDs(t, req)
+		if len(ids) != 2 {
+			t.Errorf("refiner saw %d calls, want 2", len(ids))
+			return
+		}
+		resp := fmt.Sprintf(
+			`[{"id":%d,"jsonrpc":"2.0","error":{"code":-32000,"message":"boom"}},{"id":%d,"jsonrpc":"2.0","result":{}}]`,
+			ids[0], ids[1])
+		writeFrame(t, serverConn, []byte(resp))
+	}()
+
+	handled, errs := l.PreBatch(context.Background(), calls)
+
+	if !handled[0] || !handled[1] {
+		t.Fatalf("handled = %v, want both true", handled)
+	}
+	if errs[0] == nil {
+		t.Fatal("errs[0] = nil, want the refiner's error to propagate to the failing call")
+	}
+	if errs[1] != nil {
+		t.Fatalf("errs[1] = %v, want nil; one call failing shouldn't fail its batch-mate", errs[1])
+	}
+	if respB == nil {
+		t.Fatal("respB was never set despite its call succeeding")
+	}
+}
+
+func TestPreBatchFailsWholeChunkOnRequestBatchError(t *testing.T) {
+	rc, serverConn := newTestClient(t)
+	l := &StandaloneProxy{Config: &Config{MaxBatchSize: defaultMaxBatchSize}, client: rc}
+
+	var respA, respB any
+	calls := []BatchCall{
+		{Name: "debug_traceTransaction", Args: []any{common.H256{}}, Response: &respA},
+		{Name: "debug_traceTransaction", Args: []any{common.H256{}}, Response: &respB},
+	}
+
+	// The fake refiner drops the connection instead of answering, so the
+	// whole in-flight batch round trip fails at once.
+	go func() {
+		readFrame(t, serverConn)
+		_ = serverConn.Close()
+	}()
+
+	handled, errs := l.PreBatch(context.Background(), calls)
+
+	for i := range calls {
+		if !handled[i] {
+			t.Fatalf("call %d: handled = false, want true", i)
+		}
+		if errs[i] == nil {
+			t.Fatalf("call %d: err = nil, want the connection-loss error to fail every call in the chunk", i)
+		}
+	}
+}
+
+func sum(xs []int) int {
+	total := 0
+	for _, x := range xs {
+		total += x
+	}
+	return total
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
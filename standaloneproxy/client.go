@@ -0,0 +1,643 @@
+package standaloneproxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/aurora-is-near/relayer2-base/log"
+	"github.com/buger/jsonparser"
+)
+
+// ErrProxyUnavailable is returned when every connection in the refiner pool is
+// currently unhealthy, instead of a request blocking on (or failing against)
+// a connection we already know is down.
+var ErrProxyUnavailable = errors.New("standaloneproxy: refiner connection pool unavailable")
+
+const (
+	minReconnectBackoff = 100 * time.Millisecond
+	maxReconnectBackoff = 30 * time.Second
+)
+
+// rpcResult carries the outcome of a single in-flight request back to its caller.
+type rpcResult struct {
+	data []byte
+	err  error
+}
+
+// pendingEntry tracks which pooled connection a request was sent on, so that
+// losing that connection only fails the requests actually in flight on it.
+type pendingEntry struct {
+	ch chan rpcResult
+	pc *pooledConn
+}
+
+// pooledConn is one connection in the refiner pool, plus the health and
+// backoff bookkeeping the supervisor loop needs to manage it independently of
+// the rest of the pool.
+type pooledConn struct {
+	id int
+
+	writeMu sync.Mutex
+
+	mu         sync.Mutex
+	conn       net.Conn
+	healthy    bool
+	backoff    time.Duration
+	nextTry    time.Time
+	reconnects uint64
+	lastErr    error
+}
+
+func (pc *pooledConn) isHealthy() bool {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	return pc.healthy
+}
+
+// PoolMetrics summarizes the refiner connection pool's current health, for
+// operators to alert on refiner outages.
+type PoolMetrics struct {
+	PoolSize   int
+	Healthy    int
+	InFlight   int
+	Reconnects uint64
+	LastError  error
+}
+
+// rpcClient is a pipelined JSON-RPC client over a health-checked pool of
+// framed socket connections to the refiner. Outgoing requests are tagged with
+// a monotonically increasing id so many calls can be in flight at once; each
+// connection has its own background reader goroutine demultiplexing
+// responses back to their waiting callers as they arrive.
+type rpcClient struct {
+	network        string
+	address        string
+	timeout        time.Duration
+	healthInterval time.Duration
+
+	pool    []*pooledConn
+	rrCount uint64
+
+	nextID uint64
+
+	pendMu  sync.Mutex
+	pending map[uint64]*pendingEntry
+
+	nextSubID     uint64
+	subMu         sync.Mutex
+	subsByLocal   map[string]*activeSub
+	subsByRefiner map[string]*activeSub
+
+	closed int32
+	stopCh chan struct{}
+}
+
+func newRPCClient(network string, address string, timeout time.Duration, poolSize int, healthInterval time.Duration) (*rpcClient, error) {
+	if poolSize <= 0 {
+		poolSize = defaultPoolSize
+	}
+	if healthInterval <= 0 {
+		healthInterval = defaultHealthInterval
+	}
+
+	rc := &rpcClient{
+		network:        network,
+		address:        address,
+		timeout:        timeout,
+		healthInterval: healthInterval,
+		pending:        make(map[uint64]*pendingEntry),
+		subsByLocal:    make(map[string]*activeSub),
+		subsByRefiner:  make(map[string]*activeSub),
+		stopCh:         make(chan struct{}),
+	}
+
+	rc.pool = make([]*pooledConn, poolSize)
+	for i := range rc.pool {
+		pc := &pooledConn{id: i, backoff: minReconnectBackoff}
+		rc.pool[i] = pc
+		if err := rc.dial(pc); err != nil {
+			log.Log().Warn().Err(err).Int("conn", pc.id).Msg("standaloneproxy: initial connection to refiner failed, will retry")
+		}
+	}
+
+	go rc.superviseLoop()
+	return rc, nil
+}
+
+func (rc *rpcClient) Close() error {
+	if !atomic.CompareAndSwapInt32(&rc.closed, 0, 1) {
+		return nil
+	}
+	close(rc.stopCh)
+
+	var firstErr error
+	for _, pc := range rc.pool {
+		pc.mu.Lock()
+		conn := pc.conn
+		pc.conn = nil
+		pc.healthy = false
+		pc.mu.Unlock()
+		if conn != nil {
+			if err := conn.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// Metrics reports the current state of the connection pool.
+func (rc *rpcClient) Metrics() PoolMetrics {
+	m := PoolMetrics{PoolSize: len(rc.pool)}
+	for _, pc := range rc.pool {
+		pc.mu.Lock()
+		if pc.healthy {
+			m.Healthy++
+		}
+		m.Reconnects += pc.reconnects
+		if pc.lastErr != nil {
+			m.LastError = pc.lastErr
+		}
+		pc.mu.Unlock()
+	}
+
+	rc.pendMu.Lock()
+	m.InFlight = len(rc.pending)
+	rc.pendMu.Unlock()
+	return m
+}
+
+// dial connects pc and, on success, starts its reader goroutine. Failures
+// just mark pc unhealthy with a backoff; the supervisor loop will retry.
+func (rc *rpcClient) dial(pc *pooledConn) error {
+	if rc.network == "unix" {
+		if _, err := os.Stat(rc.address); os.IsNotExist(err) {
+			rc.markUnhealthy(pc, err)
+			return err
+		}
+	}
+
+	c, err := net.Dial(rc.network, rc.address)
+	if err != nil {
+		rc.markUnhealthy(pc, err)
+		return err
+	}
+
+	pc.mu.Lock()
+	pc.conn = c
+	pc.healthy = true
+	pc.backoff = minReconnectBackoff
+	pc.lastErr = nil
+	pc.reconnects++
+	pc.mu.Unlock()
+
+	go rc.readLoop(pc, c)
+	return nil
+}
+
+// markUnhealthy records err against pc, closes its connection if it still has
+// one, and schedules the next reconnect attempt with jittered exponential
+// backoff capped at maxReconnectBackoff.
+func (rc *rpcClient) markUnhealthy(pc *pooledConn, err error) {
+	pc.mu.Lock()
+	wasHealthy := pc.healthy
+	if pc.conn != nil {
+		_ = pc.conn.Close()
+		pc.conn = nil
+	}
+	pc.healthy = false
+	pc.lastErr = err
+
+	backoff := pc.backoff
+	if backoff <= 0 {
+		backoff = minReconnectBackoff
+	}
+	jitter := time.Duration(rand.Int63n(int64(backoff/2) + 1))
+	pc.nextTry = time.Now().Add(backoff/2 + jitter)
+	pc.backoff = backoff * 2
+	if pc.backoff > maxReconnectBackoff {
+		pc.backoff = maxReconnectBackoff
+	}
+	pc.mu.Unlock()
+
+	if wasHealthy {
+		log.Log().Warn().Err(err).Int("conn", pc.id).Msg("standaloneproxy: refiner connection unhealthy")
+	}
+
+	rc.failPendingForConn(pc, err)
+}
+
+// superviseLoop periodically health-checks every connection in the pool,
+// dials replacements for unhealthy ones whose backoff has elapsed, and
+// re-issues any subscriptions left stranded on a now-unhealthy connection.
+func (rc *rpcClient) superviseLoop() {
+	ticker := time.NewTicker(rc.healthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-rc.stopCh:
+			return
+		case <-ticker.C:
+			rc.superviseOnce()
+		}
+	}
+}
+
+func (rc *rpcClient) superviseOnce() {
+	now := time.Now()
+	for _, pc := range rc.pool {
+		pc.mu.Lock()
+		healthy := pc.healthy
+		due := now.After(pc.nextTry)
+		conn := pc.conn
+		pc.mu.Unlock()
+
+		if !healthy {
+			if due {
+				if err := rc.dial(pc); err != nil {
+					log.Log().Warn().Err(err).Int("conn", pc.id).Msg("standaloneproxy: reconnect to refiner failed")
+				}
+			}
+			continue
+		}
+
+		if err := rc.ping(pc); err != nil {
+			// onReadError may have already marked pc unhealthy for this same
+			// failure (e.g. the read loop saw the same EOF concurrently);
+			// only mark it again if ping's failure is still about the
+			// connection pc had when we started, same guard as onReadError.
+			pc.mu.Lock()
+			sameConn := pc.conn == conn
+			pc.mu.Unlock()
+			if sameConn {
+				rc.markUnhealthy(pc, err)
+			}
+		}
+	}
+
+	rc.resubscribeStale()
+	rc.logMetrics()
+}
+
+// logMetrics emits the pool's current health on every supervisor tick so
+// operators can alert on refiner outages from the existing log subsystem,
+// escalating to Error once no pooled connection is healthy.
+func (rc *rpcClient) logMetrics() {
+	m := rc.Metrics()
+	event := log.Log().Info()
+	if m.Healthy == 0 {
+		event = log.Log().Error()
+	}
+	event.Int("poolSize", m.PoolSize).
+		Int("healthy", m.Healthy).
+		Int("inFlight", m.InFlight).
+		Uint64("reconnects", m.Reconnects).
+		AnErr("lastError", m.LastError).
+		Msg("standaloneproxy: refiner connection pool status")
+}
+
+// ping performs a lightweight health check against pc using net_version.
+func (rc *rpcClient) ping(pc *pooledConn) error {
+	ctx, cancel := context.WithTimeout(context.Background(), rc.timeout)
+	defer cancel()
+	_, err := rc.requestOn(ctx, pc, "net_version")
+	return err
+}
+
+// pickConn round-robins across healthy connections in the pool, returning
+// ErrProxyUnavailable only when none of them are currently up.
+func (rc *rpcClient) pickConn() (*pooledConn, error) {
+	n := len(rc.pool)
+	start := int(atomic.AddUint64(&rc.rrCount, 1) % uint64(n))
+	for i := 0; i < n; i++ {
+		pc := rc.pool[(start+i)%n]
+		if pc.isHealthy() {
+			return pc, nil
+		}
+	}
+	return nil, ErrProxyUnavailable
+}
+
+// readLoop owns the read side of conn for its entire lifetime. It loops
+// reading length-prefixed frames and routes each one: a JSON array is a
+// batched response and gets unpacked element by element, an eth_subscription
+// notification is dispatched to its subscriber, and anything else is routed
+// by its JSON-RPC id to whichever request()/requestBatch() call is waiting on
+// it. It returns, marking pc unhealthy, as soon as conn is no longer usable.
+func (rc *rpcClient) readLoop(pc *pooledConn, conn net.Conn) {
+	for {
+		var l uint32
+		if err := binary.Read(conn, binary.LittleEndian, &l); err != nil {
+			rc.onReadError(pc, conn, err)
+			return
+		}
+
+		buf := make([]byte, l)
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			rc.onReadError(pc, conn, err)
+			return
+		}
+
+		trimmed := bytes.TrimSpace(buf)
+		if len(trimmed) > 0 && trimmed[0] == '[' {
+			if _, err := jsonparser.ArrayEach(trimmed, func(value []byte, _ jsonparser.ValueType, _ int, _ error) {
+				rc.routeFrame(pc, value)
+			}); err != nil {
+				log.Log().Warn().Err(err).Msg("standaloneproxy: dropping unparsable batch response frame")
+			}
+			continue
+		}
+
+		rc.routeFrame(pc, buf)
+	}
+}
+
+// onReadError marks pc unhealthy, unless it's already been superseded by a
+// fresh dial (in which case this goroutine's work is already done).
+func (rc *rpcClient) onReadError(pc *pooledConn, conn net.Conn, err error) {
+	pc.mu.Lock()
+	sameConn := pc.conn == conn
+	pc.mu.Unlock()
+	if !sameConn {
+		return
+	}
+	rc.markUnhealthy(pc, err)
+}
+
+// routeFrame dispatches a single JSON-RPC object, whether it arrived as a
+// standalone frame or as one element of a batched response frame.
+func (rc *rpcClient) routeFrame(pc *pooledConn, buf []byte) {
+	if method, err := jsonparser.GetString(buf, "method"); err == nil && method == "eth_subscription" {
+		rc.handleNotification(buf)
+		return
+	}
+
+	id, err := jsonparser.GetInt(buf, "id")
+	if err != nil {
+		log.Log().Warn().Err(err).Msg("standaloneproxy: dropping frame without a usable id")
+		return
+	}
+
+	rc.pendMu.Lock()
+	entry, ok := rc.pending[uint64(id)]
+	delete(rc.pending, uint64(id))
+	rc.pendMu.Unlock()
+
+	if !ok {
+		return
+	}
+	entry.ch <- rpcResult{data: buf}
+}
+
+// failPendingForConn fails every request still waiting on pc specifically,
+// leaving requests in flight on other pool connections untouched.
+func (rc *rpcClient) failPendingForConn(pc *pooledConn, err error) {
+	rc.pendMu.Lock()
+	var toFail []*pendingEntry
+	for id, entry := range rc.pending {
+		if entry.pc == pc {
+			toFail = append(toFail, entry)
+			delete(rc.pending, id)
+		}
+	}
+	rc.pendMu.Unlock()
+
+	for _, entry := range toFail {
+		entry.ch <- rpcResult{err: fmt.Errorf("connection to refiner lost: %w", err)}
+	}
+}
+
+// request sends a single JSON-RPC method call over the next healthy pooled
+// connection and waits for its matching response, honoring ctx's deadline as
+// well as the client's configured per-request timeout, whichever elapses
+// first.
+func (rc *rpcClient) request(ctx context.Context, method string, params ...any) ([]byte, error) {
+	pc, err := rc.pickConn()
+	if err != nil {
+		return nil, err
+	}
+	return rc.requestOn(ctx, pc, method, params...)
+}
+
+func (rc *rpcClient) requestOn(ctx context.Context, pc *pooledConn, method string, params ...any) ([]byte, error) {
+	id := atomic.AddUint64(&rc.nextID, 1)
+	req, err := buildRequest(id, method, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	resCh := make(chan rpcResult, 1)
+	rc.pendMu.Lock()
+	rc.pending[id] = &pendingEntry{ch: resCh, pc: pc}
+	rc.pendMu.Unlock()
+
+	if err := rc.writeTo(pc, req); err != nil {
+		rc.pendMu.Lock()
+		delete(rc.pending, id)
+		rc.pendMu.Unlock()
+		return nil, err
+	}
+
+	timer := time.AfterFunc(rc.timeout, func() {
+		rc.pendMu.Lock()
+		entry, ok := rc.pending[id]
+		delete(rc.pending, id)
+		rc.pendMu.Unlock()
+		if ok {
+			entry.ch <- rpcResult{err: fmt.Errorf("request to refiner timed out after %s", rc.timeout)}
+		}
+	})
+	defer timer.Stop()
+
+	select {
+	case res := <-resCh:
+		return res.data, res.err
+	case <-ctx.Done():
+		rc.pendMu.Lock()
+		delete(rc.pending, id)
+		rc.pendMu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// batchCall is one call coalesced into a requestBatch JSON array frame.
+type batchCall struct {
+	method string
+	params []any
+}
+
+// requestBatch sends every call in calls as a single JSON-RPC array frame
+// over one pooled connection and fans the responses back out in the same
+// order, each in its own rpcResult slot so that one call failing (or timing
+// out) doesn't fail the others.
+func (rc *rpcClient) requestBatch(ctx context.Context, calls []batchCall) ([]rpcResult, error) {
+	if len(calls) == 0 {
+		return nil, nil
+	}
+
+	pc, err := rc.pickConn()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint64, len(calls))
+	chans := make([]chan rpcResult, len(calls))
+
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, c := range calls {
+		id := atomic.AddUint64(&rc.nextID, 1)
+		req, err := buildRequest(id, c.method, c.params...)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(req)
+
+		ids[i] = id
+		chans[i] = make(chan rpcResult, 1)
+		rc.pendMu.Lock()
+		rc.pending[id] = &pendingEntry{ch: chans[i], pc: pc}
+		rc.pendMu.Unlock()
+	}
+	buf.WriteByte(']')
+
+	discardPending := func() {
+		rc.pendMu.Lock()
+		for _, id := range ids {
+			delete(rc.pending, id)
+		}
+		rc.pendMu.Unlock()
+	}
+
+	if err := rc.writeTo(pc, buf.Bytes()); err != nil {
+		discardPending()
+		return nil, err
+	}
+
+	timer := time.AfterFunc(rc.timeout, func() {
+		rc.pendMu.Lock()
+		for _, id := range ids {
+			if entry, ok := rc.pending[id]; ok {
+				delete(rc.pending, id)
+				entry.ch <- rpcResult{err: fmt.Errorf("request to refiner timed out after %s", rc.timeout)}
+			}
+		}
+		rc.pendMu.Unlock()
+	})
+	defer timer.Stop()
+
+	results := make([]rpcResult, len(calls))
+	for i, ch := range chans {
+		select {
+		case results[i] = <-ch:
+		case <-ctx.Done():
+			discardPending()
+			for j := i; j < len(calls); j++ {
+				results[j] = rpcResult{err: ctx.Err()}
+			}
+			return results, nil
+		}
+	}
+	return results, nil
+}
+
+// BatchRequest is one call to coalesce into a single socket round trip via
+// RequestBatch.
+type BatchRequest struct {
+	Method string
+	Params []any
+}
+
+// BatchResponse is the per-call outcome of a RequestBatch call, matching
+// BatchRequest slot for slot.
+type BatchResponse struct {
+	Raw []byte
+	Err error
+}
+
+// RequestBatch is the RPCClient-facing form of requestBatch.
+func (rc *rpcClient) RequestBatch(ctx context.Context, calls []BatchRequest) ([]BatchResponse, error) {
+	internal := make([]batchCall, len(calls))
+	for i, c := range calls {
+		internal[i] = batchCall{method: c.Method, params: c.Params}
+	}
+
+	results, err := rc.requestBatch(ctx, internal)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]BatchResponse, len(results))
+	for i, r := range results {
+		out[i] = BatchResponse{Raw: r.data, Err: r.err}
+	}
+	return out, nil
+}
+
+// writeTo serializes the length prefix and payload onto pc's connection. The
+// write lock is only held for the duration of the write itself, so it never
+// blocks on a response.
+func (rc *rpcClient) writeTo(pc *pooledConn, req []byte) error {
+	pc.mu.Lock()
+	conn := pc.conn
+	healthy := pc.healthy
+	pc.mu.Unlock()
+
+	if !healthy || conn == nil {
+		return ErrProxyUnavailable
+	}
+
+	pc.writeMu.Lock()
+	defer pc.writeMu.Unlock()
+
+	if err := binary.Write(conn, binary.LittleEndian, uint32(len(req))); err != nil {
+		if errors.Is(err, syscall.EPIPE) || errors.Is(err, io.EOF) {
+			rc.onReadError(pc, conn, err)
+		}
+		return err
+	}
+
+	if _, err := conn.Write(req); err != nil {
+		if errors.Is(err, syscall.EPIPE) || errors.Is(err, io.EOF) {
+			rc.onReadError(pc, conn, err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func buildRequest(id uint64, method string, params ...any) ([]byte, error) {
+	b := bytes.NewBufferString(fmt.Sprintf(`{"id":%d,"jsonrpc":"2.0","method":"%s","params":[`, id, method))
+	first := true
+	for _, param := range params {
+		p, err := json.Marshal(param)
+		if err != nil {
+			return nil, err
+		}
+
+		if !first {
+			b.WriteByte(',')
+		}
+		first = false
+
+		b.Write(p)
+	}
+	b.WriteString("]}")
+	return b.Bytes(), nil
+}
@@ -0,0 +1,164 @@
+package standaloneproxy
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/buger/jsonparser"
+)
+
+// writeFrame writes payload onto conn using the length-prefixed wire protocol
+// the refiner and rpcClient speak: a little-endian uint32 length, then the
+// payload itself.
+func writeFrame(t *testing.T, conn net.Conn, payload []byte) {
+	t.Helper()
+	if err := binary.Write(conn, binary.LittleEndian, uint32(len(payload))); err != nil {
+		t.Fatalf("writeFrame: length prefix: %v", err)
+	}
+	if _, err := conn.Write(payload); err != nil {
+		t.Fatalf("writeFrame: payload: %v", err)
+	}
+}
+
+// newTestClient builds an rpcClient around a single pooledConn wrapping the
+// client half of an in-memory pipe, without going through newRPCClient's
+// dialing (there's no real refiner to dial in tests). It returns the client
+// and the server half of the pipe for the test to act as the refiner on.
+func newTestClient(t *testing.T) (*rpcClient, net.Conn) {
+	t.Helper()
+	clientConn, serverConn := net.Pipe()
+
+	pc := &pooledConn{id: 0, conn: clientConn, healthy: true, backoff: minReconnectBackoff}
+	rc := &rpcClient{
+		timeout:       time.Second,
+		pool:          []*pooledConn{pc},
+		pending:       make(map[uint64]*pendingEntry),
+		subsByLocal:   make(map[string]*activeSub),
+		subsByRefiner: make(map[string]*activeSub),
+		stopCh:        make(chan struct{}),
+	}
+	go rc.readLoop(pc, clientConn)
+
+	t.Cleanup(func() {
+		close(rc.stopCh)
+		_ = clientConn.Close()
+		_ = serverConn.Close()
+	})
+	return rc, serverConn
+}
+
+func TestBuildRequestWritesTheGivenID(t *testing.T) {
+	seen := make(map[uint64]bool)
+	for id := uint64(1); id <= 5; id++ {
+		req, err := buildRequest(id, "net_version")
+		if err != nil {
+			t.Fatalf("buildRequest: %v", err)
+		}
+		got, err := jsonparser.GetInt(req, "id")
+		if err != nil {
+			t.Fatalf("jsonparser.GetInt: %v", err)
+		}
+		if uint64(got) != id {
+			t.Fatalf("buildRequest wrote id %d, want %d", got, id)
+		}
+		if seen[uint64(got)] {
+			t.Fatalf("id %d reused across requests", got)
+		}
+		seen[uint64(got)] = true
+	}
+}
+
+// TestRequestOnRoutesOutOfOrderResponses exercises the pipelined client: it
+// fires several requestOn calls concurrently over one connection and has the
+// fake refiner reply out of order, verifying each caller still receives the
+// response matching its own request id rather than blocking behind the
+// others.
+func TestRequestOnRoutesOutOfOrderResponses(t *testing.T) {
+	rc, serverConn := newTestClient(t)
+	pc := rc.pool[0]
+
+	const n = 5
+	go func() {
+		reqs := make([][]byte, 0, n)
+		for i := 0; i < n; i++ {
+			var l uint32
+			if err := binary.Read(serverConn, binary.LittleEndian, &l); err != nil {
+				return
+			}
+			buf := make([]byte, l)
+			if _, err := io.ReadFull(serverConn, buf); err != nil {
+				return
+			}
+			reqs = append(reqs, buf)
+		}
+		// Reply in reverse order to prove responses aren't matched positionally.
+		for i := len(reqs) - 1; i >= 0; i-- {
+			id, _ := jsonparser.GetInt(reqs[i], "id")
+			resp := []byte(fmt.Sprintf(`{"id":%d,"jsonrpc":"2.0","result":%d}`, id, id*10))
+			writeFrame(t, serverConn, resp)
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			res, err := rc.requestOn(ctx, pc, "eth_estimateGas")
+			if err != nil {
+				t.Errorf("requestOn: %v", err)
+				return
+			}
+			id, err := jsonparser.GetInt(res, "id")
+			if err != nil {
+				t.Errorf("jsonparser.GetInt(id): %v", err)
+				return
+			}
+			result, err := jsonparser.GetInt(res, "result")
+			if err != nil {
+				t.Errorf("jsonparser.GetInt(result): %v", err)
+				return
+			}
+			if result != id*10 {
+				t.Errorf("requestOn returned response for a different request: id=%d result=%d", id, result)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRequestOnFailsOnConnectionLoss(t *testing.T) {
+	rc, serverConn := newTestClient(t)
+	pc := rc.pool[0]
+
+	go func() {
+		var l uint32
+		if err := binary.Read(serverConn, binary.LittleEndian, &l); err != nil {
+			return
+		}
+		buf := make([]byte, l)
+		if _, err := io.ReadFull(serverConn, buf); err != nil {
+			return
+		}
+		// Close without ever writing a response, simulating the refiner
+		// dropping the connection mid-request.
+		_ = serverConn.Close()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := rc.requestOn(ctx, pc, "net_version"); err == nil {
+		t.Fatal("requestOn succeeded despite the connection being closed mid-flight")
+	}
+	if pc.isHealthy() {
+		t.Fatal("pooledConn still marked healthy after a read error")
+	}
+}
@@ -0,0 +1,114 @@
+package standaloneproxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/aurora-is-near/relayer2-base/types/common"
+	"github.com/aurora-is-near/relayer2-base/types/engine"
+	"github.com/aurora-is-near/relayer2-base/types/response"
+	"github.com/buger/jsonparser"
+)
+
+func (rc *rpcClient) TraceTransaction(ctx context.Context, hash common.H256) (*response.CallFrame, error) {
+	res, err := rc.request(ctx, "debug_traceTransaction", hash)
+	if err != nil {
+		return nil, err
+	}
+	return decodeTraceTransactionResponse(res)
+}
+
+func decodeTraceTransactionResponse(res []byte) (*response.CallFrame, error) {
+	result, resultType, _, err := jsonparser.Get(res, "result")
+	if err != nil && !errors.Is(err, jsonparser.KeyPathNotFoundError) {
+		return nil, err
+	}
+
+	switch resultType {
+	case jsonparser.NotExist:
+		rpcErr, rpcErrType, _, err := jsonparser.Get(res, "error", "message")
+		if err != nil || rpcErrType != jsonparser.String {
+			return nil, errors.New("internal rpc error")
+		}
+		return nil, fmt.Errorf("%s", rpcErr)
+
+	case jsonparser.Object:
+		trace := new(response.CallFrame)
+		err := json.Unmarshal(result, trace)
+		return trace, err
+
+	case jsonparser.Array:
+		traces := make([]*response.CallFrame, 0, 1)
+		_, err := jsonparser.ArrayEach(result, func(value []byte, dataType jsonparser.ValueType, _ int, _ error) {
+			trace := new(response.CallFrame)
+			err = json.Unmarshal(value, trace)
+			if err != nil {
+				return
+			}
+			traces = append(traces, trace)
+		})
+		if len(traces) != 1 {
+			return nil, errors.New("unexpected response")
+		}
+		return traces[0], err
+
+	default:
+		return nil, errors.New("failed to parse unexpected response")
+	}
+}
+
+func (rc *rpcClient) EstimateGas(ctx context.Context, tx engine.TransactionForCall, number *common.BN64) (*common.Uint256, error) {
+	var blockParam interface{} = number
+	switch *number {
+	case common.EarliestBlockNumber:
+		blockParam = "earliest"
+	case common.LatestBlockNumber:
+		blockParam = "latest"
+	case common.PendingBlockNumber:
+		blockParam = "pending"
+	}
+
+	res, err := rc.request(ctx, "eth_estimateGas", tx, blockParam)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	return parseEstimateGasResponse(res)
+}
+
+func parseEstimateGasResponse(res []byte) (*common.Uint256, error) {
+	result, resultType, _, err := jsonparser.Get(res, "result")
+
+	if err == nil && resultType == jsonparser.Number {
+		val, err := strconv.ParseInt(string(result), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse result as integer: %w", err)
+		}
+		hexStr := fmt.Sprintf("0x%x", val)
+
+		resp := new(common.Uint256)
+		if err := resp.UnmarshalJSON([]byte(hexStr)); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+		}
+		return resp, nil
+	}
+
+	return handleEstimateGasError(res)
+}
+
+func handleEstimateGasError(res []byte) (*common.Uint256, error) {
+	rpcErrData, _, _, rpcErrDataParseErr := jsonparser.Get(res, "error", "data")
+	if rpcErrDataParseErr == nil && len(rpcErrData) > 0 {
+		return nil, fmt.Errorf("engine error: %s", rpcErrData)
+	}
+
+	rpcErrMsg, _, _, rpcErrMsgParseErr := jsonparser.Get(res, "error", "message")
+	if rpcErrMsgParseErr == nil && len(rpcErrMsg) > 0 {
+		return nil, fmt.Errorf("engine error: %s", rpcErrMsg)
+	}
+
+	return nil, errors.New("engine error: unknown error occurred")
+}
@@ -0,0 +1,106 @@
+package standaloneproxy
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMarkUnhealthyBacksOffExponentiallyAndCaps(t *testing.T) {
+	rc := &rpcClient{pending: make(map[uint64]*pendingEntry)}
+	pc := &pooledConn{id: 0, healthy: true, backoff: minReconnectBackoff}
+
+	// pc.backoff holds the delay for the next failure, so it has already
+	// doubled from minReconnectBackoff once the first markUnhealthy returns.
+	wantBackoff := 2 * minReconnectBackoff
+	for i := 0; i < 10; i++ {
+		before := time.Now()
+		rc.markUnhealthy(pc, errors.New("boom"))
+
+		pc.mu.Lock()
+		healthy := pc.healthy
+		backoff := pc.backoff
+		nextTry := pc.nextTry
+		pc.mu.Unlock()
+
+		if healthy {
+			t.Fatalf("round %d: pooledConn still healthy after markUnhealthy", i)
+		}
+		if nextTry.Before(before) {
+			t.Fatalf("round %d: nextTry %s is not in the future", i, nextTry)
+		}
+		if backoff != wantBackoff {
+			t.Fatalf("round %d: backoff = %s, want %s", i, backoff, wantBackoff)
+		}
+
+		wantBackoff *= 2
+		if wantBackoff > maxReconnectBackoff {
+			wantBackoff = maxReconnectBackoff
+		}
+	}
+
+	pc.mu.Lock()
+	finalBackoff := pc.backoff
+	pc.mu.Unlock()
+	if finalBackoff != maxReconnectBackoff {
+		t.Fatalf("backoff never capped: got %s, want %s", finalBackoff, maxReconnectBackoff)
+	}
+}
+
+func TestPickConnSkipsUnhealthyAndFailsWhenAllDown(t *testing.T) {
+	rc := &rpcClient{
+		pool: []*pooledConn{
+			{id: 0, healthy: false},
+			{id: 1, healthy: false},
+		},
+		pending: make(map[uint64]*pendingEntry),
+	}
+
+	if _, err := rc.pickConn(); !errors.Is(err, ErrProxyUnavailable) {
+		t.Fatalf("pickConn() with no healthy conns = %v, want ErrProxyUnavailable", err)
+	}
+
+	rc.pool[1].healthy = true
+	pc, err := rc.pickConn()
+	if err != nil {
+		t.Fatalf("pickConn(): %v", err)
+	}
+	if pc.id != 1 {
+		t.Fatalf("pickConn() returned conn %d, want the only healthy conn (1)", pc.id)
+	}
+}
+
+func TestFailPendingForConnOnlyFailsThatConnsRequests(t *testing.T) {
+	rc := &rpcClient{pending: make(map[uint64]*pendingEntry)}
+	pcA := &pooledConn{id: 0, healthy: true}
+	pcB := &pooledConn{id: 1, healthy: true}
+
+	chA := make(chan rpcResult, 1)
+	chB := make(chan rpcResult, 1)
+	rc.pending[1] = &pendingEntry{ch: chA, pc: pcA}
+	rc.pending[2] = &pendingEntry{ch: chB, pc: pcB}
+
+	rc.failPendingForConn(pcA, errors.New("conn a lost"))
+
+	select {
+	case res := <-chA:
+		if res.err == nil {
+			t.Fatal("expected an error for the request pinned to the lost connection")
+		}
+	default:
+		t.Fatal("request pinned to the lost connection was never failed")
+	}
+
+	select {
+	case <-chB:
+		t.Fatal("request on a healthy connection should not be failed")
+	default:
+	}
+
+	rc.pendMu.Lock()
+	_, stillPending := rc.pending[2]
+	rc.pendMu.Unlock()
+	if !stillPending {
+		t.Fatal("request on a healthy connection was dropped from pending")
+	}
+}
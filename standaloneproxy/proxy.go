@@ -1,18 +1,10 @@
 package standaloneproxy
 
 import (
-	"bytes"
 	"context"
-	"encoding/binary"
 	"encoding/json"
 	"errors"
-	"fmt"
-	"io"
-	"net"
-	"os"
-	"strconv"
 	"sync"
-	"syscall"
 	"time"
 
 	"github.com/aurora-is-near/relayer2-base/cmdutils"
@@ -21,28 +13,64 @@ import (
 	"github.com/aurora-is-near/relayer2-base/types/common"
 	"github.com/aurora-is-near/relayer2-base/types/engine"
 	"github.com/aurora-is-near/relayer2-base/types/response"
-	"github.com/buger/jsonparser"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/spf13/viper"
 )
 
 const configPath = "endpoint.standaloneproxy"
 
 type RPCClient interface {
-	TraceTransaction(hash common.H256) (*response.CallFrame, error)
-	EstimateGas(tx engine.TransactionForCall, number *common.BN64) (*common.Uint256, error)
+	TraceTransaction(ctx context.Context, hash common.H256) (*response.CallFrame, error)
+	TraceCall(ctx context.Context, tx engine.TransactionForCall, blockNumberOrHash *common.BlockNumberOrHash, cfg *TracerConfig) (TraceResult, error)
+	TraceBlockByNumber(ctx context.Context, number *common.BN64, cfg *TracerConfig) (TraceResult, error)
+	TraceBlockByHash(ctx context.Context, hash common.H256, cfg *TracerConfig) (TraceResult, error)
+	TraceBlock(ctx context.Context, rlp hexutil.Bytes, cfg *TracerConfig) (TraceResult, error)
+	EstimateGas(ctx context.Context, tx engine.TransactionForCall, number *common.BN64) (*common.Uint256, error)
+	Subscribe(ctx context.Context, channel string, params []any) (string, <-chan json.RawMessage, error)
+	Unsubscribe(ctx context.Context, id string) error
+	RequestBatch(ctx context.Context, calls []BatchRequest) ([]BatchResponse, error)
+	Metrics() PoolMetrics
 	Close() error
 }
 
-type Config struct {
-	Network string        `mapstructure:"network"`
-	Address string        `mapstructure:"address"`
-	Timeout time.Duration `mapstructure:"timeout"`
+// tracerConfigArg extracts an optional trailing *TracerConfig argument, treating
+// a missing or nil argument as "use the default tracer".
+func tracerConfigArg(args []any, i int) (*TracerConfig, error) {
+	if i >= len(args) || args[i] == nil {
+		return nil, nil
+	}
+	cfg, ok := args[i].(*TracerConfig)
+	if !ok {
+		return nil, errors.New("invalid params")
+	}
+	return cfg, nil
 }
 
+type Config struct {
+	Network        string        `mapstructure:"network"`
+	Address        string        `mapstructure:"address"`
+	Timeout        time.Duration `mapstructure:"timeout"`
+	MaxBatchSize   int           `mapstructure:"maxBatchSize"`
+	PoolSize       int           `mapstructure:"poolSize"`
+	HealthInterval time.Duration `mapstructure:"healthInterval"`
+}
+
+// defaultPoolSize and defaultHealthInterval seed GetConfig's defaults, and
+// also back-stop newRPCClient in case it's ever called directly with a zero
+// value.
+const (
+	defaultPoolSize       = 4
+	defaultHealthInterval = 10 * time.Second
+)
+
 func GetConfig() *Config {
 	config := &Config{
-		Network: "unix",
-		Timeout: 5 * time.Second,
+		Network:        "unix",
+		Timeout:        5 * time.Second,
+		MaxBatchSize:   defaultMaxBatchSize,
+		PoolSize:       defaultPoolSize,
+		HealthInterval: defaultHealthInterval,
 	}
 	sub := viper.Sub(configPath)
 	if sub != nil {
@@ -58,21 +86,32 @@ func GetConfig() *Config {
 type StandaloneProxy struct {
 	Config *Config
 	client RPCClient
+
+	// subMu/subs map the rpc.ID handed back to a subscribing client to the
+	// RPCClient-level subscription id needed to unsubscribe from the refiner.
+	subMu sync.Mutex
+	subs  map[rpc.ID]string
 }
 
 func New() (*StandaloneProxy, error) {
 	conf := GetConfig()
-	client, err := newRPCClient(conf.Network, conf.Address, conf.Timeout)
+	client, err := newRPCClient(conf.Network, conf.Address, conf.Timeout, conf.PoolSize, conf.HealthInterval)
 	if err != nil {
 		return nil, err
 	}
-	return &StandaloneProxy{conf, client}, err
+	return &StandaloneProxy{Config: conf, client: client, subs: make(map[rpc.ID]string)}, err
 }
 
 func (l *StandaloneProxy) Close() error {
 	return l.client.Close()
 }
 
+// Metrics reports the current health of the refiner connection pool, for
+// operators to alert on refiner outages.
+func (l *StandaloneProxy) Metrics() PoolMetrics {
+	return l.client.Metrics()
+}
+
 // Pre implements endpoint.Processor.
 func (l *StandaloneProxy) Pre(ctx context.Context, name string, _ *endpoint.Endpoint, response *any, args ...any) (context.Context, bool, error) {
 	switch name {
@@ -84,241 +123,109 @@ func (l *StandaloneProxy) Pre(ctx context.Context, name string, _ *endpoint.Endp
 		if !ok {
 			return ctx, false, errors.New("invalid params")
 		}
-		res, err := l.client.TraceTransaction(hash)
+		res, err := l.client.TraceTransaction(ctx, hash)
 		if err != nil {
 			return ctx, true, err
 		}
 		*response = res
 		return ctx, true, nil
 
-	case "eth_estimateGas":
-		tx, ok := args[0].(engine.TransactionForCall)
-		if !ok {
-			return ctx, true, errors.New("invalid params")
+	case "debug_traceCall":
+		if len(args) < 2 {
+			return ctx, false, errors.New("invalid params")
 		}
-		blockNumberOrHash, ok := args[1].(*common.BlockNumberOrHash)
+		return l.runProxyPlan(ctx, name, args, response)
+
+	case "debug_traceBlockByNumber":
+		if len(args) < 1 {
+			return ctx, false, errors.New("invalid params")
+		}
+		return l.runProxyPlan(ctx, name, args, response)
+
+	case "debug_traceBlockByHash":
+		if len(args) < 1 {
+			return ctx, false, errors.New("invalid params")
+		}
+		return l.runProxyPlan(ctx, name, args, response)
+
+	case "debug_traceBlock":
+		if len(args) < 1 {
+			return ctx, false, errors.New("invalid params")
+		}
+		return l.runProxyPlan(ctx, name, args, response)
+
+	case "eth_subscribe":
+		if len(args) < 1 {
+			return ctx, false, errors.New("invalid params")
+		}
+		channel, ok := args[0].(string)
 		if !ok {
 			return ctx, true, errors.New("invalid params")
 		}
-		if blockNumberOrHash == nil {
-			latest := common.LatestBlockNumber
-			blockNumberOrHash = &common.BlockNumberOrHash{BlockNumber: &latest}
-		}
-		res, err := l.client.EstimateGas(tx, blockNumberOrHash.BlockNumber)
+		sub, err := l.subscribe(ctx, channel, args[1:]...)
 		if err != nil {
 			return ctx, true, err
 		}
-		*response = res
+		*response = sub
 		return ctx, true, nil
 
-	default:
-		return ctx, false, nil
-	}
-}
-
-// Post implements endpoint.Processor.
-func (*StandaloneProxy) Post(ctx context.Context, _ string, _ *any, _ *error) context.Context {
-	return ctx
-}
-
-type rpcClient struct {
-	conn    net.Conn
-	lock    sync.Mutex
-	network string
-	address string
-	timeout time.Duration
-}
-
-func newRPCClient(network string, address string, timeout time.Duration) (*rpcClient, error) {
-	return &rpcClient{
-		network: network,
-		address: address,
-		timeout: timeout,
-	}, nil
-}
-
-func (rc *rpcClient) Close() error {
-	if rc.conn == nil {
-		return nil
-	}
-	return rc.conn.Close()
-}
-
-func (rc *rpcClient) TraceTransaction(hash common.H256) (*response.CallFrame, error) {
-	req, err := buildRequest("debug_traceTransaction", hash)
-	if err != nil {
-		return nil, err
-	}
-
-	res, err := rc.request(req)
-	if err != nil {
-		return nil, err
-	}
-
-	result, resultType, _, err := jsonparser.Get(res, "result")
-	if err != nil && !errors.Is(err, jsonparser.KeyPathNotFoundError) {
-		return nil, err
-	}
-
-	switch resultType {
-	case jsonparser.NotExist:
-		rpcErr, rpcErrType, _, err := jsonparser.Get(res, "error", "message")
-		if err != nil || rpcErrType != jsonparser.String {
-			return nil, errors.New("internal rpc error")
+	case "eth_unsubscribe":
+		if len(args) != 1 {
+			return ctx, false, errors.New("invalid params")
 		}
-		return nil, fmt.Errorf("%s", rpcErr)
-
-	case jsonparser.Object:
-		trace := new(response.CallFrame)
-		err := json.Unmarshal(result, trace)
-		return trace, err
-
-	case jsonparser.Array:
-		traces := make([]*response.CallFrame, 0, 1)
-		_, err := jsonparser.ArrayEach(result, func(value []byte, dataType jsonparser.ValueType, _ int, _ error) {
-			trace := new(response.CallFrame)
-			err = json.Unmarshal(value, trace)
-			if err != nil {
-				return
-			}
-			traces = append(traces, trace)
-		})
-		if len(traces) != 1 {
-			return nil, errors.New("unexpected response")
+		id, ok := args[0].(rpc.ID)
+		if !ok {
+			return ctx, true, errors.New("invalid params")
 		}
-		return traces[0], err
-
-	default:
-		return nil, errors.New("failed to parse unexpected response")
-	}
-}
-
-func (rc *rpcClient) EstimateGas(tx engine.TransactionForCall, number *common.BN64) (*common.Uint256, error) {
-	var blockParam interface{} = number
-	switch *number {
-	case common.EarliestBlockNumber:
-		blockParam = "earliest"
-	case common.LatestBlockNumber:
-		blockParam = "latest"
-	case common.PendingBlockNumber:
-		blockParam = "pending"
-	}
-
-	req, err := buildRequest("eth_estimateGas", tx, blockParam)
-	if err != nil {
-		return nil, fmt.Errorf("failed to build request: %w", err)
-	}
-
-	res, err := rc.request(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-
-	return parseEstimateGasResponse(res)
-}
-
-func parseEstimateGasResponse(res []byte) (*common.Uint256, error) {
-	result, resultType, _, err := jsonparser.Get(res, "result")
-
-	if err == nil && resultType == jsonparser.Number {
-		val, err := strconv.ParseInt(string(result), 10, 64)
+		ok, err := l.unsubscribe(ctx, id)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse result as integer: %w", err)
+			return ctx, true, err
 		}
-		hexStr := fmt.Sprintf("0x%x", val)
+		*response = ok
+		return ctx, true, nil
 
-		resp := new(common.Uint256)
-		if err := resp.UnmarshalJSON([]byte(hexStr)); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal result: %w", err)
+	case "eth_estimateGas":
+		if len(args) < 2 {
+			return ctx, false, errors.New("invalid params")
 		}
-		return resp, nil
-	}
-
-	return handleEstimateGasError(res)
-}
-
-func handleEstimateGasError(res []byte) (*common.Uint256, error) {
-	rpcErrData, _, _, rpcErrDataParseErr := jsonparser.Get(res, "error", "data")
-	if rpcErrDataParseErr == nil && len(rpcErrData) > 0 {
-		return nil, fmt.Errorf("engine error: %s", rpcErrData)
-	}
+		return l.runProxyPlan(ctx, name, args, response)
 
-	rpcErrMsg, _, _, rpcErrMsgParseErr := jsonparser.Get(res, "error", "message")
-	if rpcErrMsgParseErr == nil && len(rpcErrMsg) > 0 {
-		return nil, fmt.Errorf("engine error: %s", rpcErrMsg)
-	}
-
-	return nil, errors.New("engine error: unknown error occurred")
-}
-
-func (rc *rpcClient) reconnect() error {
-	_, err := os.Stat(rc.address)
-	if os.IsNotExist(err) {
-		return errors.New("socket connection to refiner is not available. Trying to reconnect. Please try again")
-	}
-	c, err := net.Dial(rc.network, rc.address)
-	if err != nil {
-		return errors.New("socket connection to refiner is not available. Trying to reconnect. Please try again")
-	} else {
-		rc.conn = c
-		return nil
+	default:
+		return ctx, false, nil
 	}
 }
 
-func (rc *rpcClient) request(req []byte) ([]byte, error) {
-	if rc.conn == nil {
-		if err := rc.reconnect(); err != nil {
-			return nil, err
-		}
-	}
-	rc.lock.Lock()
-	defer rc.lock.Unlock()
-	err := rc.conn.SetDeadline(time.Now().Add(rc.timeout))
+// runProxyPlan validates and shapes a proxied call's params via planProxyCall
+// and sends it to the refiner over the same RequestBatch path PreBatch uses,
+// so single-call and batched dispatch share one source of truth for param
+// shaping and response decoding instead of each keeping its own copy.
+func (l *StandaloneProxy) runProxyPlan(ctx context.Context, name string, args []any, response *any) (context.Context, bool, error) {
+	plan, err := planProxyCall(name, args)
 	if err != nil {
-		return nil, err
+		return ctx, true, err
 	}
-	err = binary.Write(rc.conn, binary.LittleEndian, uint32(len(req)))
-	if err != nil {
-		if errors.Is(err, syscall.EPIPE) {
-			if err := rc.reconnect(); err != nil {
-				return nil, err
-			}
-		}
-		return nil, err
+	if plan == nil {
+		return ctx, false, nil
 	}
 
-	_, err = rc.conn.Write(req)
+	results, err := l.client.RequestBatch(ctx, []BatchRequest{{Method: plan.method, Params: plan.params}})
 	if err != nil {
-		return nil, err
+		return ctx, true, err
+	}
+	res := results[0]
+	if res.Err != nil {
+		return ctx, true, res.Err
 	}
 
-	var l uint32
-	err = binary.Read(rc.conn, binary.LittleEndian, &l)
+	decoded, err := plan.decode(res.Raw)
 	if err != nil {
-		return nil, err
+		return ctx, true, err
 	}
-
-	buf := make([]byte, l)
-	_, err = io.ReadFull(rc.conn, buf)
-	return buf, err
+	*response = decoded
+	return ctx, true, nil
 }
 
-func buildRequest(method string, params ...any) ([]byte, error) {
-	b := bytes.NewBufferString(`{"id":1,"jsonrpc":"2.0","method":"` + method + `","params":[`)
-	first := true
-	for _, param := range params {
-		p, err := json.Marshal(param)
-		if err != nil {
-			return nil, err
-		}
-
-		if !first {
-			b.WriteByte(',')
-		}
-		first = false
-
-		b.Write(p)
-	}
-	b.WriteString("]}")
-	return b.Bytes(), nil
+// Post implements endpoint.Processor.
+func (*StandaloneProxy) Post(ctx context.Context, _ string, _ *any, _ *error) context.Context {
+	return ctx
 }
@@ -0,0 +1,239 @@
+package standaloneproxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/aurora-is-near/relayer2-base/log"
+	"github.com/buger/jsonparser"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// subscriptionBuffer bounds how many un-delivered notifications we'll hold for
+// a slow subscriber; once it is full, each newly arriving notification is
+// dropped and the buffered ones are kept.
+const subscriptionBuffer = 256
+
+// subscriptionChannels are the eth_subscribe channels the standalone proxy
+// understands and forwards to the refiner.
+var subscriptionChannels = map[string]bool{
+	"newHeads":               true,
+	"logs":                   true,
+	"newPendingTransactions": true,
+	"syncing":                true,
+}
+
+// activeSub tracks one subscription registered with the refiner. localID is
+// stable for the life of the subscription and is what callers use to
+// unsubscribe; refinerID is whatever the refiner assigned on its end, scoped
+// to the pooled connection (pc) it was registered on, and is reassigned
+// transparently by resubscribeStale whenever that connection is replaced.
+type activeSub struct {
+	localID   string
+	refinerID string
+	pc        *pooledConn
+	channel   string
+	params    []any
+	notify    chan json.RawMessage
+}
+
+// Subscribe registers a new subscription with the refiner and returns a
+// stable local id (valid across reconnects) along with the channel that
+// notifications will be delivered on.
+func (rc *rpcClient) Subscribe(ctx context.Context, channel string, params []any) (string, <-chan json.RawMessage, error) {
+	if !subscriptionChannels[channel] {
+		return "", nil, fmt.Errorf("unsupported subscription channel %q", channel)
+	}
+
+	sub := &activeSub{
+		localID: fmt.Sprintf("local-%d", atomic.AddUint64(&rc.nextSubID, 1)),
+		channel: channel,
+		params:  params,
+		notify:  make(chan json.RawMessage, subscriptionBuffer),
+	}
+
+	if err := rc.sendSubscribe(ctx, sub); err != nil {
+		return "", nil, err
+	}
+
+	rc.subMu.Lock()
+	rc.subsByLocal[sub.localID] = sub
+	rc.subMu.Unlock()
+
+	return sub.localID, sub.notify, nil
+}
+
+// Unsubscribe tears down the local bookkeeping for id and tells the refiner to
+// stop sending notifications for it.
+func (rc *rpcClient) Unsubscribe(ctx context.Context, id string) error {
+	rc.subMu.Lock()
+	sub, ok := rc.subsByLocal[id]
+	if ok {
+		delete(rc.subsByLocal, id)
+		delete(rc.subsByRefiner, sub.refinerID)
+	}
+	rc.subMu.Unlock()
+
+	if !ok {
+		return nil
+	}
+	close(sub.notify)
+
+	if !sub.pc.isHealthy() {
+		// The connection that owns this subscription is already gone, so the
+		// refiner has already forgotten it; nothing left to tell it.
+		return nil
+	}
+	_, err := rc.requestOn(ctx, sub.pc, "eth_unsubscribe", sub.refinerID)
+	return err
+}
+
+// sendSubscribe issues the eth_subscribe call for sub over a healthy pooled
+// connection and records both the connection and the refiner-assigned
+// subscription id it comes back with.
+func (rc *rpcClient) sendSubscribe(ctx context.Context, sub *activeSub) error {
+	pc, err := rc.pickConn()
+	if err != nil {
+		return err
+	}
+
+	res, err := rc.requestOn(ctx, pc, "eth_subscribe", append([]any{sub.channel}, sub.params...)...)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+
+	raw, _, _, err := jsonparser.Get(res, "result")
+	if err != nil {
+		return fmt.Errorf("failed to parse subscription id: %w", err)
+	}
+	refinerID := strings.Trim(string(raw), `"`)
+
+	rc.subMu.Lock()
+	sub.refinerID = refinerID
+	sub.pc = pc
+	rc.subsByRefiner[refinerID] = sub
+	rc.subMu.Unlock()
+	return nil
+}
+
+// resubscribeStale re-issues every subscription whose refinerID is no longer
+// known to the pool (because the connection that registered it was lost and
+// replaced), so that a socket drop doesn't silently stop delivery to clients
+// that are still listening. It's called from the health-check supervisor
+// loop rather than eagerly on every reconnect, since a request-driven
+// resubscribe would otherwise race the very dial that triggered it.
+func (rc *rpcClient) resubscribeStale() {
+	rc.subMu.Lock()
+	var stale []*activeSub
+	for _, sub := range rc.subsByLocal {
+		if sub.pc == nil || !sub.pc.isHealthy() {
+			delete(rc.subsByRefiner, sub.refinerID)
+			stale = append(stale, sub)
+		}
+	}
+	rc.subMu.Unlock()
+
+	for _, sub := range stale {
+		if err := rc.sendSubscribe(context.Background(), sub); err != nil {
+			log.Log().Warn().Err(err).Str("channel", sub.channel).
+				Msg("standaloneproxy: failed to re-subscribe after reconnect")
+		}
+	}
+}
+
+// handleNotification routes an eth_subscription frame to its subscriber.
+func (rc *rpcClient) handleNotification(buf []byte) {
+	refinerID, err := jsonparser.GetString(buf, "params", "subscription")
+	if err != nil {
+		log.Log().Warn().Err(err).Msg("standaloneproxy: dropping malformed subscription notification")
+		return
+	}
+
+	result, _, _, err := jsonparser.Get(buf, "params", "result")
+	if err != nil {
+		log.Log().Warn().Err(err).Msg("standaloneproxy: dropping subscription notification without a result")
+		return
+	}
+
+	rc.subMu.Lock()
+	sub, ok := rc.subsByRefiner[refinerID]
+	rc.subMu.Unlock()
+	if !ok {
+		return
+	}
+
+	select {
+	case sub.notify <- json.RawMessage(result):
+	default:
+		log.Log().Warn().Str("channel", sub.channel).Msg("standaloneproxy: dropping notification, subscriber too slow")
+	}
+}
+
+// forwardSubscription pumps notifications for localID from the refiner client
+// onto the go-ethereum-style notifier subscription until either side tears
+// down, and unsubscribes from the refiner when it's done.
+func (l *StandaloneProxy) forwardSubscription(notifier *rpc.Notifier, rpcSub *rpc.Subscription, localID string, notifyCh <-chan json.RawMessage) {
+	defer func() {
+		l.subMu.Lock()
+		delete(l.subs, rpcSub.ID)
+		l.subMu.Unlock()
+		_ = l.client.Unsubscribe(context.Background(), localID)
+	}()
+
+	for {
+		select {
+		case payload, ok := <-notifyCh:
+			if !ok {
+				return
+			}
+			if err := notifier.Notify(rpcSub.ID, payload); err != nil {
+				return
+			}
+		case <-rpcSub.Err():
+			return
+		case <-notifier.Closed():
+			return
+		}
+	}
+}
+
+func (l *StandaloneProxy) subscribe(ctx context.Context, channel string, params ...any) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+
+	localID, notifyCh, err := l.client.Subscribe(ctx, channel, params)
+	if err != nil {
+		return nil, err
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	l.subMu.Lock()
+	l.subs[rpcSub.ID] = localID
+	l.subMu.Unlock()
+
+	go l.forwardSubscription(notifier, rpcSub, localID, notifyCh)
+
+	return rpcSub, nil
+}
+
+func (l *StandaloneProxy) unsubscribe(ctx context.Context, id rpc.ID) (bool, error) {
+	l.subMu.Lock()
+	localID, ok := l.subs[id]
+	delete(l.subs, id)
+	l.subMu.Unlock()
+
+	if !ok {
+		return false, errors.New("subscription not found")
+	}
+	if err := l.client.Unsubscribe(ctx, localID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,182 @@
+package standaloneproxy
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/buger/jsonparser"
+)
+
+// readFrame reads one length-prefixed frame off conn, the inverse of writeFrame.
+func readFrame(t *testing.T, conn net.Conn) []byte {
+	t.Helper()
+	var l uint32
+	if err := binary.Read(conn, binary.LittleEndian, &l); err != nil {
+		t.Fatalf("readFrame: length prefix: %v", err)
+	}
+	buf := make([]byte, l)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("readFrame: payload: %v", err)
+	}
+	return buf
+}
+
+func TestSubscribeRejectsUnsupportedChannel(t *testing.T) {
+	rc, _ := newTestClient(t)
+
+	if _, _, err := rc.Subscribe(context.Background(), "notAChannel", nil); err == nil {
+		t.Fatal("Subscribe succeeded for an unsupported channel")
+	}
+}
+
+func TestSubscribeRegistersAndRoutesNotifications(t *testing.T) {
+	rc, serverConn := newTestClient(t)
+
+	go func() {
+		req := readFrame(t, serverConn)
+		id, _ := jsonparser.GetInt(req, "id")
+		resp := []byte(fmt.Sprintf(`{"id":%d,"jsonrpc":"2.0","result":"0xsub1"}`, id))
+		writeFrame(t, serverConn, resp)
+
+		// The refiner can push a notification at any point after the
+		// subscription id comes back, unprompted by a further request.
+		writeFrame(t, serverConn, []byte(
+			`{"jsonrpc":"2.0","method":"eth_subscription",`+
+				`"params":{"subscription":"0xsub1","result":{"number":"0x1"}}}`))
+	}()
+
+	localID, notifyCh, err := rc.Subscribe(context.Background(), "newHeads", nil)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	if localID == "" {
+		t.Fatal("Subscribe returned an empty local id")
+	}
+
+	select {
+	case payload := <-notifyCh:
+		if string(payload) != `{"number":"0x1"}` {
+			t.Fatalf("notification payload = %s, want %s", payload, `{"number":"0x1"}`)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the notification to be routed to the subscriber")
+	}
+}
+
+func TestUnsubscribeSendsEthUnsubscribeAndClosesChannel(t *testing.T) {
+	rc, serverConn := newTestClient(t)
+
+	go func() {
+		req := readFrame(t, serverConn)
+		id, _ := jsonparser.GetInt(req, "id")
+		writeFrame(t, serverConn, []byte(fmt.Sprintf(`{"id":%d,"jsonrpc":"2.0","result":"0xsub2"}`, id)))
+	}()
+
+	localID, notifyCh, err := rc.Subscribe(context.Background(), "logs", nil)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	unsubDone := make(chan error, 1)
+	go func() {
+		unsubDone <- rc.Unsubscribe(context.Background(), localID)
+	}()
+
+	req := readFrame(t, serverConn)
+	method, _ := jsonparser.GetString(req, "method")
+	if method != "eth_unsubscribe" {
+		t.Fatalf("Unsubscribe sent method %q, want eth_unsubscribe", method)
+	}
+	params, _, _, err := jsonparser.Get(req, "params")
+	if err != nil {
+		t.Fatalf("jsonparser.Get(params): %v", err)
+	}
+	var args []string
+	if err := json.Unmarshal(params, &args); err != nil {
+		t.Fatalf("unmarshal params: %v", err)
+	}
+	if len(args) != 1 || args[0] != "0xsub2" {
+		t.Fatalf("Unsubscribe params = %v, want [0xsub2]", args)
+	}
+	id, _ := jsonparser.GetInt(req, "id")
+	writeFrame(t, serverConn, []byte(fmt.Sprintf(`{"id":%d,"jsonrpc":"2.0","result":true}`, id)))
+
+	if err := <-unsubDone; err != nil {
+		t.Fatalf("Unsubscribe: %v", err)
+	}
+
+	if _, ok := <-notifyCh; ok {
+		t.Fatal("notify channel was not closed by Unsubscribe")
+	}
+}
+
+func TestUnsubscribeSkipsRequestOnDeadConn(t *testing.T) {
+	rc, serverConn := newTestClient(t)
+
+	go func() {
+		req := readFrame(t, serverConn)
+		id, _ := jsonparser.GetInt(req, "id")
+		writeFrame(t, serverConn, []byte(fmt.Sprintf(`{"id":%d,"jsonrpc":"2.0","result":"0xsub3"}`, id)))
+	}()
+
+	localID, _, err := rc.Subscribe(context.Background(), "syncing", nil)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	rc.pool[0].mu.Lock()
+	rc.pool[0].healthy = false
+	rc.pool[0].mu.Unlock()
+
+	if err := rc.Unsubscribe(context.Background(), localID); err != nil {
+		t.Fatalf("Unsubscribe on a dead connection returned an error: %v", err)
+	}
+}
+
+func TestResubscribeStaleReregistersSubscriptionsOnLostConns(t *testing.T) {
+	rc, serverConn := newTestClient(t)
+
+	deadPC := &pooledConn{id: 1, healthy: false}
+	sub := &activeSub{
+		localID:   "local-stale",
+		refinerID: "0xold",
+		pc:        deadPC,
+		channel:   "newHeads",
+		notify:    make(chan json.RawMessage, subscriptionBuffer),
+	}
+	rc.subMu.Lock()
+	rc.subsByLocal[sub.localID] = sub
+	rc.subsByRefiner[sub.refinerID] = sub
+	rc.subMu.Unlock()
+
+	go func() {
+		req := readFrame(t, serverConn)
+		method, _ := jsonparser.GetString(req, "method")
+		if method != "eth_subscribe" {
+			t.Errorf("resubscribeStale sent method %q, want eth_subscribe", method)
+		}
+		id, _ := jsonparser.GetInt(req, "id")
+		writeFrame(t, serverConn, []byte(fmt.Sprintf(`{"id":%d,"jsonrpc":"2.0","result":"0xnew"}`, id)))
+	}()
+
+	rc.resubscribeStale()
+
+	rc.subMu.Lock()
+	defer rc.subMu.Unlock()
+	if _, stillThere := rc.subsByRefiner["0xold"]; stillThere {
+		t.Fatal("resubscribeStale left the stale refiner id registered")
+	}
+	refreshed, ok := rc.subsByRefiner["0xnew"]
+	if !ok {
+		t.Fatal("resubscribeStale did not register the new refiner id")
+	}
+	if refreshed.pc != rc.pool[0] {
+		t.Fatal("resubscribeStale did not move the subscription onto the healthy pooled conn")
+	}
+}
@@ -0,0 +1,235 @@
+package standaloneproxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/aurora-is-near/relayer2-base/types/common"
+	"github.com/aurora-is-near/relayer2-base/types/engine"
+	"github.com/aurora-is-near/relayer2-base/types/response"
+	"github.com/buger/jsonparser"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// TracerConfig mirrors the second/third parameter accepted by go-ethereum's
+// debug_trace* family: it selects which tracer the refiner should run and
+// tunes its output. An empty Tracer means the default structLog tracer.
+type TracerConfig struct {
+	Tracer           string          `json:"tracer,omitempty"`
+	Timeout          string          `json:"timeout,omitempty"`
+	TracerConfig     json.RawMessage `json:"tracerConfig,omitempty"`
+	DisableStack     bool            `json:"disableStack,omitempty"`
+	DisableStorage   bool            `json:"disableStorage,omitempty"`
+	EnableMemory     bool            `json:"enableMemory,omitempty"`
+	EnableReturnData bool            `json:"enableReturnData,omitempty"`
+}
+
+// name returns the tracer this config selects, defaulting to the structLog tracer.
+func (tc *TracerConfig) name() string {
+	if tc == nil || tc.Tracer == "" {
+		return "structLog"
+	}
+	return tc.Tracer
+}
+
+// TraceResult is a discriminated union over the shapes debug_trace* can return:
+// structured opcode logs (the default structLog tracer), a CallFrame
+// (callTracer), an opaque prestate/4byte map, or (for the block-tracing
+// methods) a BlockTraceResult wrapping one of the above per transaction.
+// Exactly one concrete type is returned per call, determined by the method
+// called and the tracer named in the request's TracerConfig.
+type TraceResult interface {
+	isTraceResult()
+}
+
+// TxTraceResult is one transaction's outcome within a BlockTraceResult,
+// mirroring go-ethereum's []*txTraceResult: either Result or Error is set,
+// never both.
+type TxTraceResult struct {
+	TxHash common.H256 `json:"txHash"`
+	Result TraceResult `json:"result,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// BlockTraceResult is returned by debug_traceBlockByNumber,
+// debug_traceBlockByHash, and debug_traceBlock, which trace every transaction
+// in the block rather than a single call.
+type BlockTraceResult struct {
+	Txs []TxTraceResult
+}
+
+func (BlockTraceResult) isTraceResult() {}
+
+// StructLogResult is returned by the default structLog tracer. The refiner's
+// structLog payload is passed through unparsed since callers generally forward
+// it verbatim to the RPC response.
+type StructLogResult struct {
+	Raw json.RawMessage
+}
+
+func (StructLogResult) isTraceResult() {}
+
+// MarshalJSON returns Raw verbatim, so a StructLogResult serializes as the
+// refiner's structLog payload itself rather than as {"Raw":...}.
+func (r StructLogResult) MarshalJSON() ([]byte, error) {
+	return r.Raw, nil
+}
+
+// UnmarshalJSON stores raw verbatim without parsing it.
+func (r *StructLogResult) UnmarshalJSON(raw []byte) error {
+	r.Raw = append(r.Raw[:0], raw...)
+	return nil
+}
+
+// CallFrameResult is returned when TracerConfig.Tracer is "callTracer".
+type CallFrameResult struct {
+	*response.CallFrame
+}
+
+func (CallFrameResult) isTraceResult() {}
+
+// PrestateResult is returned by tracers whose output is a keyed map rather than
+// a call tree or opcode log, e.g. "prestateTracer" or "4byteTracer".
+type PrestateResult struct {
+	Raw json.RawMessage
+}
+
+func (PrestateResult) isTraceResult() {}
+
+// MarshalJSON returns Raw verbatim, so a PrestateResult serializes as the
+// refiner's prestate/4byte payload itself rather than as {"Raw":...}.
+func (r PrestateResult) MarshalJSON() ([]byte, error) {
+	return r.Raw, nil
+}
+
+// UnmarshalJSON stores raw verbatim without parsing it.
+func (r *PrestateResult) UnmarshalJSON(raw []byte) error {
+	r.Raw = append(r.Raw[:0], raw...)
+	return nil
+}
+
+// traceResultDecoders is keyed by tracer name so new tracers can be supported
+// without touching Pre or the RPCClient methods below.
+var traceResultDecoders = map[string]func(raw []byte) (TraceResult, error){
+	"callTracer": func(raw []byte) (TraceResult, error) {
+		frame := new(response.CallFrame)
+		if err := json.Unmarshal(raw, frame); err != nil {
+			return nil, err
+		}
+		return CallFrameResult{frame}, nil
+	},
+	"prestateTracer": func(raw []byte) (TraceResult, error) {
+		return PrestateResult{Raw: json.RawMessage(raw)}, nil
+	},
+	"4byteTracer": func(raw []byte) (TraceResult, error) {
+		return PrestateResult{Raw: json.RawMessage(raw)}, nil
+	},
+}
+
+// decodeSingleTraceResult decodes raw as the result of tracing one call with
+// tracer, dispatching through traceResultDecoders and falling back to the
+// default structLog tracer for anything not in that table.
+func decodeSingleTraceResult(tracer string, raw []byte) (TraceResult, error) {
+	decode, ok := traceResultDecoders[tracer]
+	if !ok {
+		decode = func(raw []byte) (TraceResult, error) {
+			return StructLogResult{Raw: json.RawMessage(raw)}, nil
+		}
+	}
+	return decode(raw)
+}
+
+// decodeTraceResponse decodes the "result" of a debug_trace* RPC response.
+// Block-tracing methods (debug_traceBlockByNumber, debug_traceBlockByHash,
+// debug_traceBlock) trace every transaction in the block and return a JSON
+// array of per-tx results rather than a single trace object, so isBlock picks
+// the right shape to decode into.
+func decodeTraceResponse(tracer string, res []byte, isBlock bool) (TraceResult, error) {
+	result, resultType, _, err := jsonparser.Get(res, "result")
+	if err != nil && !errors.Is(err, jsonparser.KeyPathNotFoundError) {
+		return nil, err
+	}
+	if resultType == jsonparser.NotExist {
+		rpcErr, rpcErrType, _, err := jsonparser.Get(res, "error", "message")
+		if err != nil || rpcErrType != jsonparser.String {
+			return nil, errors.New("internal rpc error")
+		}
+		return nil, fmt.Errorf("%s", rpcErr)
+	}
+
+	if !isBlock {
+		return decodeSingleTraceResult(tracer, result)
+	}
+
+	var txs []TxTraceResult
+	var arrErr error
+	if _, err := jsonparser.ArrayEach(result, func(value []byte, _ jsonparser.ValueType, _ int, _ error) {
+		if arrErr != nil {
+			return
+		}
+		var txHash common.H256
+		if hexStr, err := jsonparser.GetString(value, "txHash"); err == nil {
+			if err := txHash.UnmarshalJSON([]byte(hexStr)); err != nil {
+				arrErr = fmt.Errorf("block trace element has invalid txHash: %w", err)
+				return
+			}
+		}
+
+		if errMsg, errType, _, _ := jsonparser.Get(value, "error"); errType == jsonparser.String {
+			txs = append(txs, TxTraceResult{TxHash: txHash, Error: string(errMsg)})
+			return
+		}
+		traceRaw, _, _, err := jsonparser.Get(value, "result")
+		if err != nil {
+			arrErr = fmt.Errorf("block trace element missing result: %w", err)
+			return
+		}
+		decoded, err := decodeSingleTraceResult(tracer, traceRaw)
+		if err != nil {
+			arrErr = err
+			return
+		}
+		txs = append(txs, TxTraceResult{TxHash: txHash, Result: decoded})
+	}); err != nil {
+		return nil, fmt.Errorf("failed to parse block trace result: %w", err)
+	}
+	if arrErr != nil {
+		return nil, arrErr
+	}
+
+	return BlockTraceResult{Txs: txs}, nil
+}
+
+func (rc *rpcClient) TraceCall(ctx context.Context, tx engine.TransactionForCall, blockNumberOrHash *common.BlockNumberOrHash, cfg *TracerConfig) (TraceResult, error) {
+	res, err := rc.request(ctx, "debug_traceCall", tx, blockNumberOrHash, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	return decodeTraceResponse(cfg.name(), res, false)
+}
+
+func (rc *rpcClient) TraceBlockByNumber(ctx context.Context, number *common.BN64, cfg *TracerConfig) (TraceResult, error) {
+	res, err := rc.request(ctx, "debug_traceBlockByNumber", number, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	return decodeTraceResponse(cfg.name(), res, true)
+}
+
+func (rc *rpcClient) TraceBlockByHash(ctx context.Context, hash common.H256, cfg *TracerConfig) (TraceResult, error) {
+	res, err := rc.request(ctx, "debug_traceBlockByHash", hash, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	return decodeTraceResponse(cfg.name(), res, true)
+}
+
+func (rc *rpcClient) TraceBlock(ctx context.Context, rlp hexutil.Bytes, cfg *TracerConfig) (TraceResult, error) {
+	res, err := rc.request(ctx, "debug_traceBlock", rlp, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	return decodeTraceResponse(cfg.name(), res, true)
+}
@@ -0,0 +1,117 @@
+package standaloneproxy
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDecodeTraceResponseSingleCallDefaultsToStructLog(t *testing.T) {
+	res := []byte(`{"jsonrpc":"2.0","id":1,"result":{"gas":21000,"structLogs":[]}}`)
+
+	got, err := decodeTraceResponse("", res, false)
+	if err != nil {
+		t.Fatalf("decodeTraceResponse: %v", err)
+	}
+	sl, ok := got.(StructLogResult)
+	if !ok {
+		t.Fatalf("decodeTraceResponse returned %T, want StructLogResult", got)
+	}
+	if string(sl.Raw) != `{"gas":21000,"structLogs":[]}` {
+		t.Fatalf("StructLogResult.Raw = %s, want the result verbatim", sl.Raw)
+	}
+}
+
+func TestDecodeTraceResponseSingleCallPrestateTracer(t *testing.T) {
+	res := []byte(`{"jsonrpc":"2.0","id":1,"result":{"0x1":{"balance":"0x0"}}}`)
+
+	got, err := decodeTraceResponse("prestateTracer", res, false)
+	if err != nil {
+		t.Fatalf("decodeTraceResponse: %v", err)
+	}
+	ps, ok := got.(PrestateResult)
+	if !ok {
+		t.Fatalf("decodeTraceResponse returned %T, want PrestateResult", got)
+	}
+	if string(ps.Raw) != `{"0x1":{"balance":"0x0"}}` {
+		t.Fatalf("PrestateResult.Raw = %s, want the result verbatim", ps.Raw)
+	}
+}
+
+func TestDecodeTraceResponseSingleCallRPCError(t *testing.T) {
+	res := []byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"execution reverted"}}`)
+
+	_, err := decodeTraceResponse("", res, false)
+	if err == nil || !strings.Contains(err.Error(), "execution reverted") {
+		t.Fatalf("decodeTraceResponse error = %v, want it to surface the rpc error message", err)
+	}
+}
+
+func TestDecodeTraceResponseBlockDecodesPerTxResults(t *testing.T) {
+	hash := "0x" + strings.Repeat("11", 32)
+	res := []byte(`{"jsonrpc":"2.0","id":1,"result":[` +
+		`{"txHash":"` + hash + `","result":{"gas":1,"structLogs":[]}}` +
+		`]}`)
+
+	got, err := decodeTraceResponse("", res, true)
+	if err != nil {
+		t.Fatalf("decodeTraceResponse: %v", err)
+	}
+	block, ok := got.(BlockTraceResult)
+	if !ok {
+		t.Fatalf("decodeTraceResponse returned %T, want BlockTraceResult", got)
+	}
+	if len(block.Txs) != 1 {
+		t.Fatalf("BlockTraceResult.Txs has %d entries, want 1", len(block.Txs))
+	}
+	tx := block.Txs[0]
+	if tx.Error != "" {
+		t.Fatalf("tx.Error = %q, want empty for a successful trace", tx.Error)
+	}
+	sl, ok := tx.Result.(StructLogResult)
+	if !ok {
+		t.Fatalf("tx.Result = %T, want StructLogResult", tx.Result)
+	}
+	if string(sl.Raw) != `{"gas":1,"structLogs":[]}` {
+		t.Fatalf("tx.Result.Raw = %s, want the nested result verbatim", sl.Raw)
+	}
+
+	raw, err := json.Marshal(tx.TxHash)
+	if err != nil {
+		t.Fatalf("json.Marshal(tx.TxHash): %v", err)
+	}
+	if strings.Trim(string(raw), `"`) != hash {
+		t.Fatalf("tx.TxHash round-tripped to %s, want %s", raw, hash)
+	}
+}
+
+func TestDecodeTraceResponseBlockPropagatesPerTxError(t *testing.T) {
+	hash := "0x" + strings.Repeat("22", 32)
+	res := []byte(`{"jsonrpc":"2.0","id":1,"result":[` +
+		`{"txHash":"` + hash + `","error":"execution reverted"}` +
+		`]}`)
+
+	got, err := decodeTraceResponse("", res, true)
+	if err != nil {
+		t.Fatalf("decodeTraceResponse: %v", err)
+	}
+	block := got.(BlockTraceResult)
+	if len(block.Txs) != 1 {
+		t.Fatalf("BlockTraceResult.Txs has %d entries, want 1", len(block.Txs))
+	}
+	if block.Txs[0].Error != "execution reverted" {
+		t.Fatalf("tx.Error = %q, want %q", block.Txs[0].Error, "execution reverted")
+	}
+	if block.Txs[0].Result != nil {
+		t.Fatalf("tx.Result = %v, want nil for a per-tx error", block.Txs[0].Result)
+	}
+}
+
+func TestDecodeTraceResponseBlockRejectsMalformedElement(t *testing.T) {
+	hash := "0x" + strings.Repeat("33", 32)
+	res := []byte(`{"jsonrpc":"2.0","id":1,"result":[{"txHash":"` + hash + `"}]}`)
+
+	if _, err := decodeTraceResponse("", res, true); err == nil {
+		t.Fatal("decodeTraceResponse succeeded on a block element with neither result nor error")
+	}
+}